@@ -9,7 +9,6 @@ import (
 	"github.com/containers/conmon-rs/internal/proto"
 	"github.com/containers/podman/v3/libpod/define"
 	"github.com/containers/podman/v3/pkg/kubeutils"
-	"github.com/containers/podman/v3/utils"
 	"github.com/pkg/errors"
 )
 
@@ -31,7 +30,9 @@ type AttachConfig struct {
 	ID string
 	// Path of the attach socket.
 	SocketPath string
-	// ExecSession ID, if this is an attach for an Exec.
+	// ExecSession ID, if this is an attach for an Exec. Routing this to the
+	// exec session's own PTY/pipes is a server-side (conmon-rs) concern;
+	// see the NOTE in exec.go for the current state of that work.
 	ExecSession string
 	// Whether a terminal was setup for the command this is attaching to.
 	Tty bool
@@ -50,8 +51,24 @@ type AttachConfig struct {
 	// A closure to be run after the streams are attached.
 	// This could be used to notify callers the streams have been attached.
 	PostAttachFunc func() error
-	// The keys that indicate the attach session should be detached.
+	// The keys that indicate the attach session should be detached. Use
+	// ProcessDetachKeys to build this from a spec like "ctrl-p,ctrl-q".
 	DetachKeys []byte
+	// OnDetach, if set, is called when the attach session ends because the
+	// caller typed the detach key sequence, rather than stdin reaching EOF.
+	// This lets higher-level code react (e.g. skip --rm cleanup) without
+	// string-matching errors.
+	OnDetach func()
+	// AttachAndStart, if true, makes AttachContainer start the container
+	// itself via the StartContainer RPC once the attach streams are fully
+	// wired, instead of requiring the caller to start it beforehand (e.g.
+	// via PreAttachFunc), which can otherwise race and drop the first
+	// chunks of output for very short-lived containers.
+	AttachAndStart bool
+	// Started, if set, receives a single value once the StartContainer RPC
+	// triggered by AttachAndStart returns: true on success, false if it
+	// failed. Only used when AttachAndStart is true.
+	Started chan<- bool
 }
 
 func (c *ConmonClient) AttachContainer(ctx context.Context, cfg *AttachConfig) error {
@@ -73,7 +90,11 @@ func (c *ConmonClient) AttachContainer(ctx context.Context, cfg *AttachConfig) e
 		if err := req.SetSocketPath(cfg.SocketPath); err != nil {
 			return err
 		}
-		// TODO: add exec session
+		if cfg.ExecSession != "" {
+			if err := req.SetExecSession(cfg.ExecSession); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	defer free()
@@ -100,6 +121,15 @@ func (c *ConmonClient) attach(ctx context.Context, cfg *AttachConfig) error {
 
 		kubeutils.HandleResizing(cfg.Resize, func(size define.TerminalSize) {
 			c.logger.Debugf("Got a resize event: %+v", size)
+			if cfg.ExecSession != "" {
+				if err := c.SetWindowSizeExecSession(ctx, &SetWindowSizeExecSessionConfig{
+					ExecSession: cfg.ExecSession,
+					Size:        &size,
+				}); err != nil {
+					c.logger.Debugf("Failed to write to control file to resize terminal: %v", err)
+				}
+				return
+			}
 			if err := c.SetWindowSizeContainer(ctx, &SetWindowSizeContainerConfig{
 				ID:   cfg.ID,
 				Size: &size,
@@ -130,13 +160,26 @@ func (c *ConmonClient) attach(ctx context.Context, cfg *AttachConfig) error {
 	}
 
 	receiveStdoutError, stdinDone := c.setupStdioChannels(cfg, conn)
+
+	var startErrCh chan error
+	if cfg.AttachAndStart {
+		startErrCh = make(chan error, 1)
+		go func() {
+			startErr := c.StartContainer(ctx, cfg.ID)
+			if cfg.Started != nil {
+				cfg.Started <- startErr == nil
+			}
+			startErrCh <- startErr
+		}()
+	}
+
 	if cfg.PostAttachFunc != nil {
 		if err := cfg.PostAttachFunc(); err != nil {
 			return err
 		}
 	}
 
-	return c.readStdio(cfg, conn, receiveStdoutError, stdinDone)
+	return c.readStdio(cfg, conn, receiveStdoutError, stdinDone, startErrCh)
 }
 func (c *ConmonClient) setupStdioChannels(cfg *AttachConfig, conn *net.UnixConn) (chan error, chan error) {
 	receiveStdoutError := make(chan error)
@@ -148,7 +191,7 @@ func (c *ConmonClient) setupStdioChannels(cfg *AttachConfig, conn *net.UnixConn)
 	go func() {
 		var err error
 		if cfg.Streams.AttachStdin {
-			_, err = utils.CopyDetachable(conn, cfg.Streams.Stdin, cfg.DetachKeys)
+			_, err = copyDetachable(conn, cfg.Streams.Stdin, cfg.DetachKeys)
 		}
 		stdinDone <- err
 	}()
@@ -200,35 +243,102 @@ func (c *ConmonClient) redirectResponseToOutputStreams(cfg *AttachConfig, conn i
 	return err
 }
 
-func (c *ConmonClient) readStdio(cfg *AttachConfig, conn *net.UnixConn, receiveStdoutError, stdinDone chan error) error {
-	var err error
-	select {
-	case err = <-receiveStdoutError:
-		conn.CloseWrite()
-		return err
-	case err = <-stdinDone:
-		// This particular case is for when we get a non-tty attach
-		// with --leave-stdin-open=true. We want to return as soon
-		// as we receive EOF from the client. However, we should do
-		// this only when stdin is enabled. If there is no stdin
-		// enabled then we wait for output as usual.
-		if cfg.StopAfterStdinEOF {
+func (c *ConmonClient) readStdio(cfg *AttachConfig, conn *net.UnixConn, receiveStdoutError, stdinDone, startErrCh chan error) error {
+	// waitForStart blocks, if necessary, until the AttachAndStart goroutine's
+	// StartContainer RPC has returned. It must be called before finalizing
+	// on receiveStdoutError/stdinDone: those can resolve immediately (e.g.
+	// stdinDone sends nil right away when AttachStdin is false), which would
+	// otherwise race ahead of a slower, failing start and be reported to the
+	// caller as a plain EOF instead of the real start error.
+	waitForStart := func() error {
+		if startErrCh == nil {
 			return nil
 		}
-		if err == define.ErrDetach {
+		return <-startErrCh
+	}
+
+	var err error
+	for {
+		select {
+		case err = <-startErrCh:
+			startErrCh = nil
+			if err != nil {
+				conn.CloseWrite()
+				return errors.Wrap(err, "start container for attach")
+			}
+			// The container started successfully; keep waiting on the
+			// remaining streams as usual.
+			continue
+		case err = <-receiveStdoutError:
+			if startErr := waitForStart(); startErr != nil {
+				conn.CloseWrite()
+				return errors.Wrap(startErr, "start container for attach")
+			}
 			conn.CloseWrite()
 			return err
-		}
-		if err == nil {
-			// copy stdin is done, close it
-			if connErr := conn.CloseWrite(); connErr != nil {
-				c.logger.Errorf("Unable to close conn: %v", connErr)
+		case err = <-stdinDone:
+			if startErr := waitForStart(); startErr != nil {
+				conn.CloseWrite()
+				return errors.Wrap(startErr, "start container for attach")
+			}
+			// This particular case is for when we get a non-tty attach
+			// with --leave-stdin-open=true. We want to return as soon
+			// as we receive EOF from the client. However, we should do
+			// this only when stdin is enabled. If there is no stdin
+			// enabled then we wait for output as usual.
+			if cfg.StopAfterStdinEOF && err != ErrDetach {
+				return nil
+			}
+			if err == ErrDetach {
+				if cfg.OnDetach != nil {
+					cfg.OnDetach()
+				}
+				conn.CloseWrite()
+				return err
+			}
+			if err == nil {
+				// copy stdin is done, close it
+				if connErr := conn.CloseWrite(); connErr != nil {
+					c.logger.Errorf("Unable to close conn: %v", connErr)
+				}
+			}
+			if cfg.Streams.AttachStdout || cfg.Streams.AttachStderr {
+				return <-receiveStdoutError
 			}
+			return nil
 		}
-		if cfg.Streams.AttachStdout || cfg.Streams.AttachStderr {
-			return <-receiveStdoutError
+	}
+}
+
+// StartContainer starts the container with the given ID. It is used
+// directly by AttachConfig.AttachAndStart to start a container only once
+// its attach streams are fully wired, but can also be called on its own.
+func (c *ConmonClient) StartContainer(ctx context.Context, id string) error {
+	conn, err := c.newRPCConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := proto.Conmon{Client: conn.Bootstrap(ctx)}
+
+	future, free := client.StartContainer(ctx, func(p proto.Conmon_startContainer_Params) error {
+		req, err := p.NewRequest()
+		if err != nil {
+			return err
 		}
+		return req.SetId(id)
+	})
+	defer free()
+
+	result, err := future.Struct()
+	if err != nil {
+		return err
 	}
+
+	if _, err := result.Response(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -274,3 +384,48 @@ func (c *ConmonClient) SetWindowSizeContainer(ctx context.Context, cfg *SetWindo
 
 	return nil
 }
+
+type SetWindowSizeExecSessionConfig struct {
+	ExecSession string
+	Size        *define.TerminalSize
+}
+
+// SetWindowSizeExecSession behaves like SetWindowSizeContainer, but targets
+// the PTY of a running exec session rather than the container's primary one.
+func (c *ConmonClient) SetWindowSizeExecSession(ctx context.Context, cfg *SetWindowSizeExecSessionConfig) error {
+	if cfg.Size == nil {
+		return fmt.Errorf("Terminal size cannot be nil")
+	}
+
+	conn, err := c.newRPCConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := proto.Conmon{Client: conn.Bootstrap(ctx)}
+
+	future, free := client.SetWindowSizeExecSession(ctx, func(p proto.Conmon_setWindowSizeExecSession_Params) error {
+		req, err := p.NewRequest()
+		if err != nil {
+			return err
+		}
+		if err := req.SetExecSession(cfg.ExecSession); err != nil {
+			return err
+		}
+		req.SetWidth(cfg.Size.Width)
+		req.SetHeight(cfg.Size.Height)
+		return p.SetRequest(req)
+	})
+	defer free()
+
+	result, err := future.Struct()
+	if err != nil {
+		return err
+	}
+
+	if _, err := result.Response(); err != nil {
+		return err
+	}
+
+	return nil
+}
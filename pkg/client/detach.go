@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DetachKeysDefault is the default detach key sequence, matching Podman's
+// own default of ctrl-p,ctrl-q.
+const DetachKeysDefault = "ctrl-p,ctrl-q"
+
+// ErrDetach is returned by AttachContainer when the attach session ends
+// because the caller typed the configured detach key sequence on stdin,
+// as opposed to stdin simply reaching EOF. It is defined here rather than
+// reused from podman/v3's define.ErrDetach so that callers of this module
+// don't need to depend on libpod just to check for it.
+var ErrDetach = errors.New("detached from container")
+
+// ProcessDetachKeys parses a detach key specification in the
+// "ctrl-<letter>[,ctrl-<letter>...]" grammar used by Podman (for example
+// "ctrl-p,ctrl-q") into the raw byte sequence expected by AttachConfig.DetachKeys.
+func ProcessDetachKeys(spec string) ([]byte, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	keys := make([]byte, 0, len(spec))
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		if !strings.HasPrefix(key, "ctrl-") {
+			return nil, fmt.Errorf("invalid detach key %q: must be of the form ctrl-<letter>", key)
+		}
+
+		letter := key[len("ctrl-"):]
+		if len(letter) != 1 {
+			return nil, fmt.Errorf("invalid detach key %q: ctrl sequence must be a single character", key)
+		}
+
+		b := letter[0]
+		switch {
+		case b >= 'a' && b <= 'z':
+			keys = append(keys, b-'a'+1)
+		case b == '@':
+			keys = append(keys, 0)
+		case b == '[':
+			keys = append(keys, 27)
+		case b == '\\':
+			keys = append(keys, 28)
+		case b == ']':
+			keys = append(keys, 29)
+		case b == '^':
+			keys = append(keys, 30)
+		case b == '_':
+			keys = append(keys, 31)
+		default:
+			return nil, fmt.Errorf("invalid detach key %q", key)
+		}
+	}
+
+	return keys, nil
+}
+
+// copyDetachable copies from src to dst until src reaches EOF, returning
+// ErrDetach the instant the keys sequence appears in the stream. Bytes that
+// started matching the sequence but diverged before it completed are
+// flushed to dst unchanged, so a caller who almost-but-not-quite types the
+// detach sequence doesn't lose input.
+func copyDetachable(dst io.Writer, src io.Reader, keys []byte) (written int64, err error) {
+	if len(keys) == 0 {
+		return io.Copy(dst, src)
+	}
+
+	buf := make([]byte, 32*1024)
+	matched := 0 // len(keys[:matched]) trailing bytes read so far match keys
+
+	for {
+		nr, er := src.Read(buf)
+		if nr > 0 {
+			data := buf[:nr]
+			start := 0 // start of the region of data not yet flushed to dst
+			for i := 0; i < len(data); i++ {
+				for matched > 0 && data[i] != keys[matched] {
+					// The oldest bytes held back were not actually part of a
+					// detach sequence; flush them as regular input, keeping
+					// any tail of the held bytes that still begins the sequence.
+					drop := 1
+					for drop < matched && !bytes.Equal(keys[drop:matched], keys[:matched-drop]) {
+						drop++
+					}
+					if _, ew := dst.Write(keys[:drop]); ew != nil {
+						return written, ew
+					}
+					written += int64(drop)
+					matched -= drop
+				}
+				if data[i] != keys[matched] {
+					continue
+				}
+
+				if start < i {
+					if _, ew := dst.Write(data[start:i]); ew != nil {
+						return written, ew
+					}
+					written += int64(i - start)
+				}
+				start = i + 1
+				matched++
+				if matched == len(keys) {
+					return written, ErrDetach
+				}
+			}
+			if start < len(data) {
+				if _, ew := dst.Write(data[start:]); ew != nil {
+					return written, ew
+				}
+				written += int64(len(data) - start)
+			}
+		}
+		if er == io.EOF {
+			if matched > 0 {
+				if _, ew := dst.Write(keys[:matched]); ew != nil {
+					return written, ew
+				}
+				written += int64(matched)
+			}
+			return written, nil
+		}
+		if er != nil {
+			return written, er
+		}
+	}
+}
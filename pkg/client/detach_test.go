@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// chunkReader serves each element of chunks from a separate Read call,
+// so tests can exercise a detach sequence split across multiple reads.
+type chunkReader struct {
+	chunks [][]byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func ctrlPQ(t *testing.T) []byte {
+	t.Helper()
+	keys, err := ProcessDetachKeys(DetachKeysDefault)
+	if err != nil {
+		t.Fatalf("ProcessDetachKeys(%q): %v", DetachKeysDefault, err)
+	}
+	return keys
+}
+
+func TestCopyDetachableNoMatchPassesThroughOnEOF(t *testing.T) {
+	keys := ctrlPQ(t)
+	input := []byte("hello world, nothing special here")
+
+	var dst bytes.Buffer
+	written, err := copyDetachable(&dst, bytes.NewReader(input), keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written != int64(len(input)) {
+		t.Fatalf("written = %d, want %d", written, len(input))
+	}
+	if dst.String() != string(input) {
+		t.Fatalf("dst = %q, want %q", dst.String(), input)
+	}
+}
+
+func TestCopyDetachableFullMatchReturnsErrDetach(t *testing.T) {
+	keys := ctrlPQ(t)
+	prefix := []byte("some output before detach")
+	input := append(append([]byte{}, prefix...), keys...)
+
+	var dst bytes.Buffer
+	_, err := copyDetachable(&dst, bytes.NewReader(input), keys)
+	if !errors.Is(err, ErrDetach) {
+		t.Fatalf("err = %v, want ErrDetach", err)
+	}
+	if dst.String() != string(prefix) {
+		t.Fatalf("dst = %q, want %q", dst.String(), prefix)
+	}
+}
+
+func TestCopyDetachableFalseStartIsFlushedVerbatim(t *testing.T) {
+	keys := ctrlPQ(t) // ctrl-p, ctrl-q
+	// ctrl-p followed by 'x' is a false start: it must be flushed as-is,
+	// in order, before the real (later) detach sequence is recognized.
+	input := []byte{keys[0], 'x', keys[0], keys[1]}
+
+	var dst bytes.Buffer
+	_, err := copyDetachable(&dst, bytes.NewReader(input), keys)
+	if !errors.Is(err, ErrDetach) {
+		t.Fatalf("err = %v, want ErrDetach", err)
+	}
+	want := []byte{keys[0], 'x'}
+	if !bytes.Equal(dst.Bytes(), want) {
+		t.Fatalf("dst = %v, want %v", dst.Bytes(), want)
+	}
+}
+
+func TestCopyDetachableMultiKeySplitAcrossReads(t *testing.T) {
+	keys := ctrlPQ(t) // ctrl-p, ctrl-q
+	src := &chunkReader{chunks: [][]byte{
+		[]byte("hi"),
+		{keys[0]},
+		{keys[1]},
+	}}
+
+	var dst bytes.Buffer
+	_, err := copyDetachable(&dst, src, keys)
+	if !errors.Is(err, ErrDetach) {
+		t.Fatalf("err = %v, want ErrDetach", err)
+	}
+	if dst.String() != "hi" {
+		t.Fatalf("dst = %q, want %q", dst.String(), "hi")
+	}
+}
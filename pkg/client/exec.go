@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+
+	"github.com/containers/conmon-rs/internal/proto"
+)
+
+// NOTE: CreateExecSession, StartExecSession, and the ExecSession-routing
+// additions to AttachContainer/SetWindowSizeExecSession in attach.go are
+// client-side only. They call into proto.Conmon_createExecSession_Params,
+// proto.Conmon_startExecSession_Params, and the corresponding exec-session
+// fields/methods as if they already existed on the generated Cap'n Proto
+// bindings, but the .capnp schema has not been extended, and conmon-rs's
+// Rust server has not been taught to route an attach to an exec session's
+// PTY/pipes. None of that lives in this Go module, so it isn't part of
+// this commit. This package compiles against those bindings once the
+// schema and server-side routing land; until then, calling these methods
+// against a real conmon-rs server will fail at the RPC layer.
+
+// CreateExecSessionConfig is the configuration for CreateExecSession.
+type CreateExecSessionConfig struct {
+	// ID of the container to exec into.
+	ID string
+	// Command to run inside the container.
+	Command []string
+	// Whether a terminal should be allocated for the exec session.
+	Tty bool
+}
+
+// CreateExecSession asks conmon-rs to create a new exec session for the
+// given container and command, without starting it. It returns the ID of
+// the created exec session, which callers then pass to StartExecSession
+// and as AttachConfig.ExecSession to attach to its PTY/pipes.
+func (c *ConmonClient) CreateExecSession(ctx context.Context, cfg *CreateExecSessionConfig) (string, error) {
+	conn, err := c.newRPCConn()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	client := proto.Conmon{Client: conn.Bootstrap(ctx)}
+	future, free := client.CreateExecSession(ctx, func(p proto.Conmon_createExecSession_Params) error {
+		req, err := p.NewRequest()
+		if err != nil {
+			return err
+		}
+		if err := req.SetId(cfg.ID); err != nil {
+			return err
+		}
+		command, err := req.NewCommand(int32(len(cfg.Command)))
+		if err != nil {
+			return err
+		}
+		for i, arg := range cfg.Command {
+			if err := command.Set(i, arg); err != nil {
+				return err
+			}
+		}
+		req.SetTty(cfg.Tty)
+		return p.SetRequest(req)
+	})
+	defer free()
+
+	result, err := future.Struct()
+	if err != nil {
+		return "", err
+	}
+
+	response, err := result.Response()
+	if err != nil {
+		return "", err
+	}
+
+	return response.ExecSession()
+}
+
+// StartExecSessionConfig is the configuration for StartExecSession.
+type StartExecSessionConfig struct {
+	// ID of the exec session to start, as returned by CreateExecSession.
+	ExecSession string
+}
+
+// StartExecSession starts a previously created exec session. Callers
+// typically start the exec session and then call AttachContainer with
+// AttachConfig.ExecSession set, mirroring a `podman exec` flow without
+// shelling out to podman itself.
+func (c *ConmonClient) StartExecSession(ctx context.Context, cfg *StartExecSessionConfig) error {
+	conn, err := c.newRPCConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := proto.Conmon{Client: conn.Bootstrap(ctx)}
+	future, free := client.StartExecSession(ctx, func(p proto.Conmon_startExecSession_Params) error {
+		req, err := p.NewRequest()
+		if err != nil {
+			return err
+		}
+		return req.SetExecSession(cfg.ExecSession)
+	})
+	defer free()
+
+	result, err := future.Struct()
+	if err != nil {
+		return err
+	}
+
+	if _, err := result.Response(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build freebsd
+// +build freebsd
+
+package client
+
+import "net"
+
+// DialLongSocket dials a unix socket at address. FreeBSD has no
+// /proc/self/fd equivalent for working around sun_path's length limit, so
+// this keeps the previous, direct behavior and remains subject to that
+// platform's sun_path length limit.
+func DialLongSocket(network, address string) (*net.UnixConn, error) {
+	addr, err := net.ResolveUnixAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.DialUnix(network, nil, addr)
+}
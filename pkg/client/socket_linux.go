@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+package client
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// DialLongSocket dials a unix socket at address, working around the
+// ~108 byte length limit of sockaddr_un.sun_path. conmon's attach socket
+// paths under /run/.../attach regularly exceed that limit in rootless and
+// CRI-O scenarios, so instead of dialing the path directly, it is opened
+// with O_PATH to obtain an fd to the socket inode, and the dial is then
+// made through /proc/self/fd/N instead. The kernel resolves that path to
+// the already-opened inode regardless of how long the original path was.
+// This is the same technique podman's openUnixSocket uses.
+func DialLongSocket(network, address string) (*net.UnixConn, error) {
+	fd, err := unix.Open(address, unix.O_PATH, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open socket path %s: %w", address, err)
+	}
+	defer unix.Close(fd)
+
+	conn, err := net.DialUnix(network, nil, &net.UnixAddr{
+		Name: fmt.Sprintf("/proc/self/fd/%d", fd),
+		Net:  network,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dial long socket %s: %w", address, err)
+	}
+
+	return conn, nil
+}
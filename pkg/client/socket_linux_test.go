@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package client
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// longSocketDir nests temp directories under base until the resulting
+// directory path exceeds 108 bytes (the length of sockaddr_un.sun_path),
+// so a socket created inside it cannot be dialed directly.
+func longSocketDir(t *testing.T, base string) string {
+	t.Helper()
+
+	dir := base
+	for len(dir) <= 108 {
+		var err error
+		dir, err = os.MkdirTemp(dir, strings.Repeat("a", 32))
+		if err != nil {
+			t.Fatalf("MkdirTemp: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestDialLongSocket(t *testing.T) {
+	dir := longSocketDir(t, t.TempDir())
+	path := filepath.Join(dir, "attach")
+	if len(path) <= 108 {
+		t.Fatalf("test setup error: socket path %q is not longer than 108 bytes", path)
+	}
+
+	// net.ListenUnix is itself subject to sun_path's length limit, so the
+	// listener has to be created via a short, cwd-relative name; only the
+	// dial side (the thing this test actually exercises) goes through the
+	// long absolute path.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("Chdir back: %v", err)
+		}
+	}()
+
+	ln, err := net.ListenUnix("unixpacket", &net.UnixAddr{Name: "attach", Net: "unixpacket"})
+	if err != nil {
+		t.Fatalf("ListenUnix: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+
+	conn, err := DialLongSocket("unixpacket", path)
+	if err != nil {
+		t.Fatalf("DialLongSocket(%q): %v", path, err)
+	}
+	defer conn.Close()
+
+	if err := <-accepted; err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+}